@@ -17,6 +17,8 @@ package lager
 
 import (
 	"archive/zip"
+	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -28,9 +30,32 @@ import (
 	"sync"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
+
 	"github.com/go-chassis/openlog"
 )
 
+// Compress algorithms supported by doBackup. CompressZip is the default,
+// kept for compatibility with backups written before gzip/zstd support landed.
+const (
+	CompressNone = "none"
+	CompressZip  = "zip"
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// compressExtension returns the file suffix doBackup appends for algorithm.
+func compressExtension(algorithm string) string {
+	switch algorithm {
+	case CompressGzip:
+		return ".gz"
+	case CompressZstd:
+		return ".zst"
+	default:
+		return ".zip"
+	}
+}
+
 var pathReplacer *strings.Replacer
 
 // EscapPath escape path
@@ -53,114 +78,377 @@ func removeFile(path string) error {
 	return nil
 }
 
-func removeExceededFiles(path string, baseFileName string,
-	maxKeptCount int, rotateStage string) {
-	if maxKeptCount < 0 {
-		return
+// compressedExts lists the extensions compressFile may append; stripped
+// before parsing a backup file's embedded timestamp.
+var compressedExts = []string{".zip", ".gz", ".zst"}
+
+// backupFileAge returns how old a rotated/backup file is. It prefers the
+// timestamp embedded in the name (a SizeLimitRotateRule's 17-digit suffix or
+// a DailyRotateRule's 2006-01-02 suffix) and falls back to the file's mtime
+// when the name doesn't carry one it recognizes.
+func backupFileAge(filePath string) (time.Time, bool) {
+	name := filePath
+	for _, ext := range compressedExts {
+		name = strings.TrimSuffix(name, ext)
 	}
-	var pat string
-	if rotateStage == "rollover" {
-		//rotated file, svc.log.20060102150405000
-		pat = fmt.Sprintf(`%s\.[0-9]{1,17}$`, baseFileName)
-	} else if rotateStage == "backup" {
-		//backup compressed file, svc.log.20060102150405000.zip
-		pat = fmt.Sprintf(`%s\.[0-9]{17}\.zip$`, baseFileName)
-	} else {
+	suffix := name[strings.LastIndex(name, ".")+1:]
+	//the last 3 of the 17 digits are milliseconds, which a layout can't
+	//express without a separator, so only the first 14 are parsed
+	if len(suffix) == 17 {
+		if t, err := time.Parse("20060102150405", suffix[:14]); err == nil {
+			return t, true
+		}
+	}
+	if t, err := time.Parse(dailyBackupLayout, suffix); err == nil {
+		return t, true
+	}
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return fileInfo.ModTime(), true
+}
+
+// filesOlderThan returns the members of fileList whose backupFileAge is past
+// maxAgeDays. A non-positive maxAgeDays disables age-based trimming.
+func filesOlderThan(fileList []string, maxAgeDays int) []string {
+	if maxAgeDays <= 0 {
+		return nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -maxAgeDays)
+	var outdated []string
+	for _, f := range fileList {
+		if t, ok := backupFileAge(f); ok && t.Before(cutoff) {
+			outdated = append(outdated, f)
+		}
+	}
+	return outdated
+}
+
+// filesExceedingCount returns the oldest entries of the already-sorted
+// fileList that fall outside maxKeptCount. A negative maxKeptCount disables
+// count-based trimming.
+func filesExceedingCount(fileList []string, maxKeptCount int) []string {
+	if maxKeptCount < 0 || len(fileList) <= maxKeptCount {
+		return nil
+	}
+	return fileList[:len(fileList)-maxKeptCount]
+}
+
+// union merges fileList's own entries that appear in either a or b,
+// preserving fileList's order and removing duplicates.
+func union(fileList []string, a, b []string) []string {
+	keep := make(map[string]struct{}, len(a)+len(b))
+	for _, f := range a {
+		keep[f] = struct{}{}
+	}
+	for _, f := range b {
+		keep[f] = struct{}{}
+	}
+	result := make([]string, 0, len(keep))
+	for _, f := range fileList {
+		if _, ok := keep[f]; ok {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// removeExceededBackupFiles trims compressed backup files (e.g.
+// svc.log.<ts>.gz), removing whatever violates maxKeptCount or maxAgeDays.
+// Rollover-stage cleanup is handled by the file's RotateRule.OutdatedFiles
+// instead.
+func removeExceededBackupFiles(path string, baseFileName string, maxKeptCount int, maxAgeDays int, algorithm string) {
+	if maxKeptCount < 0 && maxAgeDays <= 0 {
 		return
 	}
+	//backup compressed file, svc.log.20060102150405000.gz
+	pat := fmt.Sprintf(`%s\.[0-9]{17}%s$`, baseFileName, regexp.QuoteMeta(compressExtension(algorithm)))
 	fileList, err := FilterFileList(path, pat)
 	if err != nil {
 		Logger.Error(fmt.Sprintf("filepath.Walk() path: %s failed: %s", EscapPath(path), err))
 		return
 	}
 	sort.Strings(fileList)
-	if len(fileList) <= maxKeptCount {
-		return
-	}
-	//remove exceeded files, keep file count below maxBackupCount
-	for len(fileList) > maxKeptCount {
-		filePath := fileList[0]
-		err := removeFile(filePath)
-		if err != nil {
+
+	for _, filePath := range union(fileList, filesExceedingCount(fileList, maxKeptCount), filesOlderThan(fileList, maxAgeDays)) {
+		if err := removeFile(filePath); err != nil {
 			Logger.Error(fmt.Sprintf("remove filePath: %s failed: %s", EscapPath(filePath), err))
-			break
 		}
-		//remove the first element of a list
-		fileList = append(fileList[:0], fileList[1:]...)
 	}
 }
 
 //filePath: file full path, like ${_APP_LOG_DIR}/svc.log.1
 //fileBaseName: rollover file base name, like svc.log
 //replaceTimestamp: whether or not to replace the num. of a rolled file
-func compressFile(filePath, fileBaseName string, replaceTimestamp bool) error {
+//algorithm: CompressZip, CompressGzip or CompressZstd
+func compressFile(filePath, fileBaseName string, replaceTimestamp bool, algorithm string) error {
 	ifp, err := os.Open(filePath)
 	if err != nil {
 		return err
 	}
 	defer ifp.Close()
 
-	var zipFilePath string
+	var outFilePath string
 	if replaceTimestamp {
-		//svc.log.1 -> svc.log.20060102150405000.zip
-		zipFileBase := fileBaseName + "." + getTimeStamp() + "." + "zip"
-		zipFilePath = filepath.Dir(filePath) + "/" + zipFileBase
+		//svc.log.1 -> svc.log.20060102150405000.gz
+		outFileBase := fileBaseName + "." + getTimeStamp() + compressExtension(algorithm)
+		outFilePath = filepath.Dir(filePath) + "/" + outFileBase
 	} else {
-		zipFilePath = filePath + ".zip"
+		outFilePath = filePath + compressExtension(algorithm)
 	}
-	zipFile, err := os.OpenFile(zipFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0440)
+	outFile, err := os.OpenFile(outFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0440)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		err := zipFile.Close()
+		err := outFile.Close()
 		if err != nil {
-			openlog.Error("can not close log zip file: " + err.Error())
+			openlog.Error("can not close log backup file: " + err.Error())
 		}
 	}()
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
-
-	ofp, err := zipWriter.Create(filepath.Base(filePath))
-	if err != nil {
+	switch algorithm {
+	case CompressGzip:
+		gzipWriter := gzip.NewWriter(outFile)
+		defer gzipWriter.Close()
+		_, err = io.Copy(gzipWriter, ifp)
+		return err
+	case CompressZstd:
+		zstdWriter, err := zstd.NewWriter(outFile)
+		if err != nil {
+			return err
+		}
+		defer zstdWriter.Close()
+		_, err = io.Copy(zstdWriter, ifp)
+		return err
+	default:
+		zipWriter := zip.NewWriter(outFile)
+		defer zipWriter.Close()
+		ofp, err := zipWriter.Create(filepath.Base(filePath))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(ofp, ifp)
 		return err
 	}
+}
 
-	_, err = io.Copy(ofp, ifp)
-	if err != nil {
-		return err
+// RotateRule decides when and how a watched log file should roll over
+type RotateRule interface {
+	// ShallRotate reports whether the file, currently of the given size, should be rotated now.
+	ShallRotate(size int64) bool
+	// BackupFileName returns the path the active file should be copied to when it rotates.
+	BackupFileName() string
+	// MarkRotated is called right after a rotation completes so the rule can reset its state.
+	MarkRotated()
+	// OutdatedFiles returns previously rotated files that no longer need to be kept.
+	OutdatedFiles() []string
+}
+
+// SizeLimitRotateRule rotates a file once it grows past RotateConfig.Size, the
+// original and still default behavior of LogRotate.
+type SizeLimitRotateRule struct {
+	rc *RotateConfig
+}
+
+// NewSizeLimitRotateRule builds a SizeLimitRotateRule for rc.
+func NewSizeLimitRotateRule(rc *RotateConfig) *SizeLimitRotateRule {
+	return &SizeLimitRotateRule{rc: rc}
+}
+
+// ShallRotate implements RotateRule.
+func (r *SizeLimitRotateRule) ShallRotate(size int64) bool {
+	if r.rc.Size < 0 {
+		return false
+	}
+	return size > int64(r.rc.Size*1024*1024)
+}
+
+// BackupFileName implements RotateRule.
+func (r *SizeLimitRotateRule) BackupFileName() string {
+	return r.rc.logFilePath + "." + getTimeStamp()
+}
+
+// MarkRotated implements RotateRule.
+func (r *SizeLimitRotateRule) MarkRotated() {}
+
+// OutdatedFiles implements RotateRule.
+func (r *SizeLimitRotateRule) OutdatedFiles() []string {
+	return outdatedRolloverFiles(r.rc.logFileDir, r.rc.logFilePath, r.rc.BackupCount, r.rc.MaxAgeDays)
+}
+
+// DailyRotateRule rotates a file once the calendar day changes, naming
+// backups like svc.log.2006-01-02.
+type DailyRotateRule struct {
+	rc      *RotateConfig
+	lastDay string
+}
+
+// NewDailyRotateRule builds a DailyRotateRule for rc.
+func NewDailyRotateRule(rc *RotateConfig) *DailyRotateRule {
+	return &DailyRotateRule{rc: rc, lastDay: time.Now().Format(dailyBackupLayout)}
+}
+
+// ShallRotate implements RotateRule.
+func (r *DailyRotateRule) ShallRotate(size int64) bool {
+	return time.Now().Format(dailyBackupLayout) != r.lastDay
+}
+
+// BackupFileName implements RotateRule.
+func (r *DailyRotateRule) BackupFileName() string {
+	return r.rc.logFilePath + "." + r.lastDay
+}
+
+// MarkRotated implements RotateRule.
+func (r *DailyRotateRule) MarkRotated() {
+	r.lastDay = time.Now().Format(dailyBackupLayout)
+}
+
+// OutdatedFiles implements RotateRule.
+func (r *DailyRotateRule) OutdatedFiles() []string {
+	return outdatedRolloverFiles(r.rc.logFileDir, r.rc.logFilePath, r.rc.BackupCount, r.rc.MaxAgeDays)
+}
+
+// SizeDailyRotateRule rotates whenever either the size limit or the daily
+// boundary is crossed, whichever comes first.
+type SizeDailyRotateRule struct {
+	size  *SizeLimitRotateRule
+	daily *DailyRotateRule
+}
+
+// NewSizeDailyRotateRule builds a SizeDailyRotateRule for rc.
+func NewSizeDailyRotateRule(rc *RotateConfig) *SizeDailyRotateRule {
+	return &SizeDailyRotateRule{size: NewSizeLimitRotateRule(rc), daily: NewDailyRotateRule(rc)}
+}
+
+// ShallRotate implements RotateRule.
+func (r *SizeDailyRotateRule) ShallRotate(size int64) bool {
+	return r.size.ShallRotate(size) || r.daily.ShallRotate(size)
+}
+
+// BackupFileName implements RotateRule.
+func (r *SizeDailyRotateRule) BackupFileName() string {
+	return r.size.BackupFileName()
+}
+
+// MarkRotated implements RotateRule.
+func (r *SizeDailyRotateRule) MarkRotated() {
+	r.daily.MarkRotated()
+}
+
+// OutdatedFiles implements RotateRule.
+func (r *SizeDailyRotateRule) OutdatedFiles() []string {
+	return r.size.OutdatedFiles()
+}
+
+// dailyBackupLayout is the reference layout used to derive day boundaries
+// and DailyRotateRule backup suffixes.
+const dailyBackupLayout = "2006-01-02"
+
+// strftimeTokens maps the subset of strftime tokens PatternRotateRule
+// understands to Go's reference-time layout.
+var strftimeTokens = strings.NewReplacer(
+	"%Y", "2006",
+	"%m", "01",
+	"%d", "02",
+	"%H", "15",
+	"%M", "04",
+)
+
+// PatternRotateRule names the active log file from a strftime-style pattern
+type PatternRotateRule struct {
+	layout   string
+	linkName string
+	current  string
+}
+
+// NewPatternRotateRule builds a PatternRotateRule for pattern, a path
+// containing %Y/%m/%d/%H/%M strftime tokens. linkName may be empty to skip
+// symlinking. After the tokens are translated the whole pattern is used as a
+// Go time layout, so any other layout element in it (such as the digits 1-6,
+// "Jan", "Mon" or "MST") is replaced by a time value as well.
+func NewPatternRotateRule(pattern, linkName string) *PatternRotateRule {
+	r := &PatternRotateRule{layout: strftimeTokens.Replace(pattern), linkName: linkName}
+	r.current = time.Now().Format(r.layout)
+	return r
+}
+
+// ShallRotate implements RotateRule; size is ignored, only the formatted
+// path matters.
+func (r *PatternRotateRule) ShallRotate(size int64) bool {
+	return time.Now().Format(r.layout) != r.current
+}
+
+// BackupFileName implements RotateRule, returning the freshly formatted
+// path the writer should switch to.
+func (r *PatternRotateRule) BackupFileName() string {
+	return time.Now().Format(r.layout)
+}
+
+// MarkRotated implements RotateRule, adopting the new path and, if linkName
+// is set, atomically re-pointing it there.
+func (r *PatternRotateRule) MarkRotated() {
+	r.current = time.Now().Format(r.layout)
+	if r.linkName == "" {
+		return
+	}
+	if err := relink(r.linkName, r.current); err != nil {
+		Logger.Error(fmt.Sprintf("relink path: %s failed: %s", EscapPath(r.linkName), err))
 	}
+}
 
+// OutdatedFiles implements RotateRule. Pattern mode has no retention yet:
+// callers are responsible for pruning old pattern-named files themselves.
+func (r *PatternRotateRule) OutdatedFiles() []string {
 	return nil
 }
 
-func shouldRollover(fPath string, MaxFileSize int) bool {
-	if MaxFileSize < 0 {
-		return false
+// relink atomically points linkName at target: a new symlink is created
+// alongside it and renamed over the old one, so nothing following linkName
+// ever observes it missing.
+func relink(linkName, target string) error {
+	tmpLink := linkName + ".tmp"
+	if err := os.Remove(tmpLink); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Symlink(target, tmpLink); err != nil {
+		return err
 	}
+	return os.Rename(tmpLink, linkName)
+}
 
-	fileInfo, err := os.Stat(fPath)
+// outdatedRolloverFiles lists the rotated-but-not-yet-compressed files for
+// baseFilePath that violate backupCount or maxAgeDays, oldest first. Both
+// limits apply independently, matching removeExceededBackupFiles.
+func outdatedRolloverFiles(dir, baseFilePath string, backupCount, maxAgeDays int) []string {
+	if backupCount < 0 && maxAgeDays <= 0 {
+		return nil
+	}
+	pat := fmt.Sprintf(`%s\.[0-9a-zA-Z-]{1,17}$`, filepath.Base(baseFilePath))
+	fileList, err := FilterFileList(dir, pat)
 	if err != nil {
-		Logger.Error(fmt.Sprintf("state path: %s failed: %s", EscapPath(fPath), err))
-		return false
+		Logger.Error(fmt.Sprintf("filepath.Walk() path: %s failed: %s", EscapPath(dir), err))
+		return nil
 	}
+	sort.Strings(fileList)
+	return union(fileList, filesExceedingCount(fileList, backupCount), filesOlderThan(fileList, maxAgeDays))
+}
 
-	if fileInfo.Size() > int64(MaxFileSize*1024*1024) {
-		return true
+func doRollover(fPath string, rule RotateRule) {
+	fileInfo, err := os.Stat(fPath)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("state path: %s failed: %s", EscapPath(fPath), err))
+		return
 	}
-	return false
-}
 
-func doRollover(fPath string, MaxFileSize int, MaxBackupCount int) {
-	if !shouldRollover(fPath, MaxFileSize) {
+	if !rule.ShallRotate(fileInfo.Size()) {
 		return
 	}
 
-	timeStamp := getTimeStamp()
 	//absolute path
-	rotateFile := fPath + "." + timeStamp
-	err := CopyFile(fPath, rotateFile)
+	rotateFile := rule.BackupFileName()
+	err = CopyFile(fPath, rotateFile)
 	if err != nil {
 		Logger.Error(fmt.Sprintf("copy path: %s failed: %s", EscapPath(fPath), err))
 	}
@@ -173,15 +461,25 @@ func doRollover(fPath string, MaxFileSize int, MaxBackupCount int) {
 	}
 	f.Close()
 
-	//remove exceeded rotate files
-	removeExceededFiles(filepath.Dir(fPath), filepath.Base(fPath), MaxBackupCount, "rollover")
+	rule.MarkRotated()
+	pruneOutdatedFiles(rule)
+}
+
+// pruneOutdatedFiles removes whatever rule.OutdatedFiles reports, logging
+// (rather than failing) individual removal errors.
+func pruneOutdatedFiles(rule RotateRule) {
+	for _, outdated := range rule.OutdatedFiles() {
+		if err := removeFile(outdated); err != nil {
+			Logger.Error(fmt.Sprintf("remove filePath: %s failed: %s", EscapPath(outdated), err))
+		}
+	}
 }
 
-func doBackup(fPath string, MaxBackupCount int) {
-	if MaxBackupCount <= 0 {
+func doBackup(fPath string, MaxBackupCount int, MaxAgeDays int, compress bool, algorithm string) {
+	if MaxBackupCount <= 0 || !compress {
 		return
 	}
-	pat := fmt.Sprintf(`%s\.[0-9]{1,17}$`, filepath.Base(fPath))
+	pat := fmt.Sprintf(`%s\.[0-9a-zA-Z-]{1,17}$`, filepath.Base(fPath))
 	rotateFileList, err := FilterFileList(filepath.Dir(fPath), pat)
 	if err != nil {
 		Logger.Error(fmt.Sprintf("walk path: %s failed: %s", EscapPath(fPath), err))
@@ -193,10 +491,10 @@ func doBackup(fPath string, MaxBackupCount int) {
 		p := fmt.Sprintf(`%s\.[0-9]{17}$`, filepath.Base(fPath))
 		if ret, _ := regexp.MatchString(p, file); ret {
 			//svc.log.20060102150405000, not replace Timestamp
-			err = compressFile(file, filepath.Base(fPath), false)
+			err = compressFile(file, filepath.Base(fPath), false, algorithm)
 		} else {
 			//svc.log.1, replace Timestamp
-			err = compressFile(file, filepath.Base(fPath), true)
+			err = compressFile(file, filepath.Base(fPath), true, algorithm)
 		}
 		if err != nil {
 			openlog.Error(fmt.Sprintf("compress path: %s failed: %s", EscapPath(file), err))
@@ -209,18 +507,18 @@ func doBackup(fPath string, MaxBackupCount int) {
 	}
 
 	//remove exceeded backup files
-	removeExceededFiles(filepath.Dir(fPath), filepath.Base(fPath), MaxBackupCount, "backup")
+	removeExceededBackupFiles(filepath.Dir(fPath), filepath.Base(fPath), MaxBackupCount, MaxAgeDays, algorithm)
 }
 
-func logRotateFile(file string, MaxFileSize int, MaxBackupCount int) {
+func logRotateFile(file string, rule RotateRule, MaxBackupCount int, MaxAgeDays int, compress bool, algorithm string) {
 	defer func() {
 		if e := recover(); e != nil {
 			Logger.Error(fmt.Sprintf("LogRotate file path: %s catch an exception.", EscapPath(file)))
 		}
 	}()
 
-	doRollover(file, MaxFileSize, MaxBackupCount)
-	doBackup(file, MaxBackupCount)
+	doRollover(file, rule)
+	doBackup(file, MaxBackupCount, MaxAgeDays, compress, algorithm)
 }
 
 // LogRotate function for log rotate
@@ -243,8 +541,196 @@ func LogRotate(path string, MaxFileSize int, MaxBackupCount int) {
 	}
 
 	for _, file := range fileList {
-		logRotateFile(file, MaxFileSize, MaxBackupCount)
+		rc := &RotateConfig{logFilePath: file, logFileDir: filepath.Dir(file), Size: MaxFileSize, BackupCount: MaxBackupCount}
+		logRotateFile(file, NewSizeLimitRotateRule(rc), MaxBackupCount, 0, true, CompressZip)
+	}
+}
+
+// RotateWriter is an io.WriteCloser that rotates rc's log file synchronously as it writes
+type RotateWriter struct {
+	rc          *RotateConfig
+	mu          sync.Mutex
+	file        *os.File
+	currentSize int64
+
+	backupCh  chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRotateWriter opens rc's log file for append and starts the background
+// goroutine that compresses and prunes files as they're rotated out. If the
+// file can't be opened, it logs the error and falls back to os.Stderr so
+// writers embedding it don't have to handle a constructor error.
+func NewRotateWriter(rc *RotateConfig) io.WriteCloser {
+	f, err := os.OpenFile(rc.logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		Logger.Error(fmt.Sprintf("open path: %s failed: %s", EscapPath(rc.logFilePath), err))
+		f = os.Stderr
+	}
+
+	var currentSize int64
+	if fileInfo, err := f.Stat(); err == nil {
+		currentSize = fileInfo.Size()
+	}
+
+	w := &RotateWriter{
+		rc:          rc,
+		file:        f,
+		currentSize: currentSize,
+		backupCh:    make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	go w.backupLoop()
+	return w
+}
+
+// Write implements io.Writer, rotating the underlying file first if p would
+// push it past rc.Rule's limit.
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.rc.Rule.ShallRotate(w.currentSize + int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			Logger.Error(fmt.Sprintf("rotate path: %s failed: %s", EscapPath(w.rc.logFilePath), err))
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.currentSize += int64(n)
+	return n, err
+}
+
+// rotate closes the active file and hands off to doRotate, reopening
+// rc.logFilePath for append if doRotate fails partway through so w.file
+// never ends up pointing at the closed fd. Caller must hold w.mu.
+func (w *RotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		Logger.Error(fmt.Sprintf("close path: %s failed: %s", EscapPath(w.rc.logFilePath), err))
+	}
+
+	err := w.doRotate()
+	if err == nil {
+		return nil
+	}
+
+	//rotation failed partway through; reopen rc.logFilePath so Write doesn't
+	//keep hitting the fd we just closed above
+	f, reopenErr := os.OpenFile(w.rc.logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if reopenErr != nil {
+		Logger.Error(fmt.Sprintf("reopen path: %s failed: %s", EscapPath(w.rc.logFilePath), reopenErr))
+		return err
+	}
+	w.file = f
+	if fileInfo, statErr := f.Stat(); statErr == nil {
+		w.currentSize = fileInfo.Size()
+	}
+	return err
+}
+
+// doRotate renames the active file out of the way and opens a fresh one in
+// its place. Caller must hold w.mu and have already closed the old fd.
+func (w *RotateWriter) doRotate() error {
+	backupFile := w.rc.Rule.BackupFileName()
+	if err := os.Rename(w.rc.logFilePath, backupFile); err != nil {
+		return err
+	}
+	w.rc.Rule.MarkRotated()
+
+	f, err := os.OpenFile(w.rc.logFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
 	}
+	w.file = f
+	w.currentSize = 0
+
+	select {
+	case w.backupCh <- struct{}{}:
+	default:
+		//a compaction is already queued, it will pick up this rotation too
+	}
+	return nil
+}
+
+// backupLoop compresses and prunes rotated-out files as they arrive on
+// backupCh, until the channel is closed by Close.
+func (w *RotateWriter) backupLoop() {
+	defer close(w.done)
+	for range w.backupCh {
+		pruneOutdatedFiles(w.rc.Rule)
+		doBackup(w.rc.logFilePath, w.rc.BackupCount, w.rc.MaxAgeDays, w.rc.Compress, w.rc.CompressAlgorithm)
+	}
+}
+
+// Close flushes any pending backup work and closes the underlying file.
+// Safe to call more than once.
+func (w *RotateWriter) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.backupCh)
+		<-w.done
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		err = w.file.Close()
+	})
+	return err
+}
+
+// PatternRotateWriter is an io.WriteCloser that writes through to whichever file its PatternRotateRule currently points at
+type PatternRotateWriter struct {
+	mu   sync.Mutex
+	rule *PatternRotateRule
+	file *os.File
+}
+
+// NewPatternRotateWriter opens pattern's current file (creating parent
+// directories as needed) and returns a writer that rolls to the next file
+// as the pattern demands, re-linking linkName along the way.
+func NewPatternRotateWriter(pattern, linkName string) (io.WriteCloser, error) {
+	rule := NewPatternRotateRule(pattern, linkName)
+	f, err := openPatternFile(rule.BackupFileName())
+	if err != nil {
+		return nil, err
+	}
+	rule.MarkRotated()
+	return &PatternRotateWriter{rule: rule, file: f}, nil
+}
+
+func openPatternFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+}
+
+// Write implements io.Writer, switching to the next pattern-named file
+// first if the strftime bucket has rolled over since the last write.
+func (w *PatternRotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.rule.ShallRotate(0) {
+		next := w.rule.BackupFileName()
+		f, err := openPatternFile(next)
+		if err != nil {
+			Logger.Error(fmt.Sprintf("open path: %s failed: %s", EscapPath(next), err))
+		} else {
+			w.file.Close()
+			w.file = f
+			w.rule.MarkRotated()
+		}
+	}
+
+	return w.file.Write(p)
+}
+
+// Close implements io.Closer.
+func (w *PatternRotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
 }
 
 // FilterFileList function for filter file list
@@ -300,11 +786,13 @@ func NewRotateConfig(option *Options) *RotateConfig {
 	}
 	rc.logFilePath = option.LoggerFile
 	rc.logFileDir = filepath.Dir(option.LoggerFile)
-	if option.RollingPolicy == RollingPolicySize {
+	if option.RollingPolicy == RollingPolicySize || option.RollingPolicy == RollingPolicySizeDaily {
 		rc.Size = LogRotateSize
 		if option.LogRotateSize > 0 {
 			rc.Size = option.LogRotateSize
 		}
+	}
+	if option.RollingPolicy == RollingPolicySize {
 		rc.CheckCycle = 30 * time.Second
 	} else {
 		rc.CheckCycle = 24 * time.Hour
@@ -312,17 +800,60 @@ func NewRotateConfig(option *Options) *RotateConfig {
 			rc.CheckCycle = 24 * time.Hour * time.Duration(option.LogRotateDate)
 		}
 	}
+
+	switch {
+	case option.RotateRule != nil:
+		//caller supplied their own rule, use it as-is
+		rc.Rule = option.RotateRule
+	case option.RollingPolicy == RollingPolicyDaily:
+		rc.Rule = NewDailyRotateRule(rc)
+	case option.RollingPolicy == RollingPolicySizeDaily:
+		rc.Rule = NewSizeDailyRotateRule(rc)
+	default:
+		rc.Rule = NewSizeLimitRotateRule(rc)
+	}
+	//RollingPolicyPattern doesn't go through RotateConfig/doBackup at all;
+	//callers use NewPatternRotateWriter directly instead.
+
+	rc.CompressAlgorithm = option.CompressAlgorithm
+	if rc.CompressAlgorithm == "" {
+		rc.CompressAlgorithm = CompressZip
+	}
+	//Options.Compress can't tell "unset" from "explicitly false", so it isn't
+	//consulted here; CompressAlgorithm == CompressNone is the only opt-out.
+	rc.Compress = rc.CompressAlgorithm != CompressNone
+	rc.MaxAgeDays = option.MaxAgeDays
 	return rc
 }
 
+// RollingPolicyDaily rotates a file once a day regardless of its size.
+// RollingPolicySizeDaily rotates whenever either the size or the daily
+// boundary is crossed, whichever comes first. RollingPolicyPattern names the
+// pattern mode, which NewRotateConfig does not handle: pattern-named files
+// are written through NewPatternRotateWriter; see PatternRotateRule.
+const (
+	RollingPolicyDaily     = "daily"
+	RollingPolicySizeDaily = "size+daily"
+	RollingPolicyPattern   = "pattern"
+)
+
 // Rotators global rotate instance
 var Rotators = &rotators{
-	logFilePaths: make(map[string]*RotateConfig, 5),
+	entries: make(map[string]*rotatorEntry, 5),
 }
 
+// rotators tracks one rotation goroutine per log file, keyed by
+// logFilePath so that two files sharing a directory each get their own
+// goroutine and cadence instead of silently overriding each other.
 type rotators struct {
-	logFilePaths map[string]*RotateConfig
-	locker       sync.Mutex
+	entries map[string]*rotatorEntry
+	locker  sync.Mutex
+}
+
+// rotatorEntry is a running rotation goroutine and the means to cancel it.
+type rotatorEntry struct {
+	rc     *RotateConfig
+	cancel context.CancelFunc
 }
 
 // RotateConfig rotate config
@@ -335,23 +866,74 @@ type RotateConfig struct {
 	CheckCycle  time.Duration
 
 	RotateDate int
+
+	// Rule decides when and how logFilePath rotates. Set by NewRotateConfig
+	// from Options.RollingPolicy, or taken verbatim from Options.RotateRule
+	// when the caller supplies a custom one.
+	Rule RotateRule
+
+	// Compress toggles whether rotated files get archived by doBackup at all.
+	// NewRotateConfig derives it from CompressAlgorithm; set both directly
+	// when building a RotateConfig by hand.
+	Compress bool
+	// CompressAlgorithm picks the archive format doBackup writes: CompressZip
+	// (default, kept for compatibility), CompressGzip, CompressZstd, or
+	// CompressNone to disable compression entirely.
+	CompressAlgorithm string
+
+	// MaxAgeDays removes rotated/backup files older than this many days, on
+	// top of (not instead of) BackupCount: a file is removed if it violates
+	// either limit. Zero or negative disables age-based trimming.
+	MaxAgeDays int
 }
 
-// Rotate rotate log
+// Rotate registers rc.logFilePath for periodic rotation and starts a
+// dedicated goroutine for it. Re-registering the same file path is a no-op;
+// registering a second file in the same directory starts its own goroutine
+// rather than being swallowed by the first.
 func (r *rotators) Rotate(rc *RotateConfig) {
 	r.locker.Lock()
 	defer r.locker.Unlock()
-	if _, exist := r.logFilePaths[rc.logFileDir]; exist {
+	if _, exist := r.entries[rc.logFilePath]; exist {
 		return
 	}
 
-	r.logFilePaths[rc.logFilePath] = rc
+	ctx, cancel := context.WithCancel(context.Background())
+	r.entries[rc.logFilePath] = &rotatorEntry{rc: rc, cancel: cancel}
 
 	go func() {
-		openlog.Info("start log rotate task")
+		openlog.Info("start log rotate task: " + EscapPath(rc.logFilePath))
+		ticker := time.NewTicker(rc.CheckCycle)
+		defer ticker.Stop()
 		for {
-			LogRotate(rc.logFileDir, rc.Size, rc.BackupCount)
-			time.Sleep(rc.CheckCycle)
+			logRotateFile(rc.logFilePath, rc.Rule, rc.BackupCount, rc.MaxAgeDays, rc.Compress, rc.CompressAlgorithm)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
 		}
 	}()
 }
+
+// Stop cancels the rotation goroutine registered for logFilePath, if any.
+func (r *rotators) Stop(logFilePath string) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	entry, exist := r.entries[logFilePath]
+	if !exist {
+		return
+	}
+	entry.cancel()
+	delete(r.entries, logFilePath)
+}
+
+// StopAll cancels every running rotation goroutine.
+func (r *rotators) StopAll() {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	for logFilePath, entry := range r.entries {
+		entry.cancel()
+		delete(r.entries, logFilePath)
+	}
+}
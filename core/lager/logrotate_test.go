@@ -0,0 +1,202 @@
+//Copyright 2017 Huawei Technologies Co., Ltd
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+
+package lager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRotatorsRotateTwoFilesInSameDir registers two files under one
+// directory and checks both get rotated on their own cadence, guarding
+// against keying rotators by directory instead of file path.
+func TestRotatorsRotateTwoFilesInSameDir(t *testing.T) {
+	dir := t.TempDir()
+	fileA := filepath.Join(dir, "a.log")
+	fileB := filepath.Join(dir, "b.log")
+	for _, f := range []string{fileA, fileB} {
+		if err := ioutil.WriteFile(f, make([]byte, 2*1024*1024), 0640); err != nil {
+			t.Fatalf("write %s: %v", f, err)
+		}
+	}
+
+	rcA := &RotateConfig{logFilePath: fileA, logFileDir: dir, Size: 1, BackupCount: 5, CheckCycle: 20 * time.Millisecond}
+	rcA.Rule = NewSizeLimitRotateRule(rcA)
+	rcB := &RotateConfig{logFilePath: fileB, logFileDir: dir, Size: 1, BackupCount: 5, CheckCycle: 20 * time.Millisecond}
+	rcB.Rule = NewSizeLimitRotateRule(rcB)
+
+	Rotators.Rotate(rcA)
+	Rotators.Rotate(rcB)
+	defer Rotators.StopAll()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if hasRotatedBackup(t, dir, "a.log") && hasRotatedBackup(t, dir, "b.log") {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected both a.log and b.log to rotate independently, got a=%v b=%v",
+				hasRotatedBackup(t, dir, "a.log"), hasRotatedBackup(t, dir, "b.log"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestNewRotateConfigSizeDailyKeepsConfiguredSize guards against rc.Size
+// only being populated for RollingPolicySize: left at zero for
+// RollingPolicySizeDaily, SizeLimitRotateRule.ShallRotate degenerates to
+// size > 0 and rotates on virtually every write.
+func TestNewRotateConfigSizeDailyKeepsConfiguredSize(t *testing.T) {
+	rc := NewRotateConfig(&Options{
+		LoggerFile:    filepath.Join(t.TempDir(), "svc.log"),
+		RollingPolicy: RollingPolicySizeDaily,
+		LogRotateSize: 200,
+	})
+	if rc.Size != 200 {
+		t.Fatalf("expected rc.Size == 200 for %s, got %d", RollingPolicySizeDaily, rc.Size)
+	}
+}
+
+// TestDoBackupCompressesDailyRotatedBackup guards against doBackup's
+// file-selection regex missing DailyRotateRule's dashed date suffix, which
+// left daily-rotated backups uncompressed forever.
+func TestDoBackupCompressesDailyRotatedBackup(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "svc.log")
+	if err := ioutil.WriteFile(logFile, []byte("hello"), 0640); err != nil {
+		t.Fatalf("write %s: %v", logFile, err)
+	}
+
+	rc := &RotateConfig{logFilePath: logFile, logFileDir: dir, BackupCount: 5}
+	rule := NewDailyRotateRule(rc)
+	rule.lastDay = "2000-01-01" // force ShallRotate to report a day change
+	rc.Rule = rule
+
+	doRollover(logFile, rule)
+	doBackup(logFile, rc.BackupCount, 0, true, CompressGzip)
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir %s: %v", dir, err)
+	}
+	var sawCompressed, sawPlainDashed bool
+	for _, e := range entries {
+		switch {
+		case strings.HasSuffix(e.Name(), compressExtension(CompressGzip)):
+			sawCompressed = true
+		case e.Name() != "svc.log" && strings.Contains(e.Name(), "2000-01-01"):
+			sawPlainDashed = true
+		}
+	}
+	if !sawCompressed {
+		t.Fatalf("expected daily-rotated backup to be compressed, got entries %v", entries)
+	}
+	if sawPlainDashed {
+		t.Fatalf("daily-rotated backup was left uncompressed, got entries %v", entries)
+	}
+}
+
+// TestRotateWriterRotatesOnSize is a behavioral test for RotateWriter: once
+// a write would push the file past rc.Rule's limit, rotation must have
+// already happened by the time Write returns.
+func TestRotateWriterRotatesOnSize(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "svc.log")
+	if err := ioutil.WriteFile(logFile, nil, 0640); err != nil {
+		t.Fatalf("create %s: %v", logFile, err)
+	}
+
+	rc := &RotateConfig{logFilePath: logFile, logFileDir: dir, Size: 1, BackupCount: 5}
+	rc.Rule = NewSizeLimitRotateRule(rc)
+	w := NewRotateWriter(rc)
+	defer w.Close()
+
+	if _, err := w.Write(make([]byte, 2*1024*1024)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if !hasRotatedBackup(t, dir, "svc.log") {
+		t.Fatalf("expected RotateWriter to rotate svc.log once it exceeded Size")
+	}
+}
+
+// TestOutdatedRolloverFilesPrunesByMaxAgeDays is a behavioral test for the
+// age-based retention added alongside BackupCount.
+func TestOutdatedRolloverFilesPrunesByMaxAgeDays(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "svc.log")
+	fresh := base + "." + time.Now().Format(dailyBackupLayout)
+	old := base + ".2000-01-01"
+	for _, f := range []string{fresh, old} {
+		if err := ioutil.WriteFile(f, nil, 0640); err != nil {
+			t.Fatalf("write %s: %v", f, err)
+		}
+	}
+
+	outdated := outdatedRolloverFiles(dir, base, -1, 30)
+	if len(outdated) != 1 || outdated[0] != old {
+		t.Fatalf("expected only %s to be outdated, got %v", old, outdated)
+	}
+}
+
+// TestPatternRotateWriterRotatesOnPatternChange is a behavioral test for
+// PatternRotateRule/PatternRotateWriter: once the pattern rolls over,
+// LinkName must point at whatever file is current.
+func TestPatternRotateWriterRotatesOnPatternChange(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "svc.%Y%m%d%H%M.log")
+	linkName := filepath.Join(dir, "svc.log")
+	wAny, err := NewPatternRotateWriter(pattern, linkName)
+	if err != nil {
+		t.Fatalf("NewPatternRotateWriter: %v", err)
+	}
+	w := wAny.(*PatternRotateWriter)
+	defer w.Close()
+
+	if _, err := w.Write([]byte("a")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	w.rule.current = "stale" // force a rollover without waiting on the clock
+	if _, err := w.Write([]byte("b")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	target, err := os.Readlink(linkName)
+	if err != nil {
+		t.Fatalf("readlink %s: %v", linkName, err)
+	}
+	if target != w.rule.current {
+		t.Fatalf("expected %s to link to the current pattern file %s, got %s", linkName, w.rule.current, target)
+	}
+}
+
+func hasRotatedBackup(t *testing.T, dir, base string) bool {
+	t.Helper()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir %s: %v", dir, err)
+	}
+	for _, e := range entries {
+		if e.Name() != base && strings.HasPrefix(e.Name(), base+".") {
+			return true
+		}
+	}
+	return false
+}